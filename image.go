@@ -0,0 +1,53 @@
+package main
+
+import "strings"
+
+// ParsedImage breaks an image reference (as stored on a container definition)
+// into its component parts. Either Tag or Digest may be empty depending on
+// how the image was pinned.
+type ParsedImage struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// parseImage parses an image reference of the form
+// [registry/]repository[:tag][@digest] the way the Docker CLI does: a
+// registry component is only recognized when it looks like a host (contains
+// a "." or ":", or is "localhost"), otherwise the leading path segment is
+// treated as part of the repository (e.g. Docker Hub's "library/nginx").
+func parseImage(image string) ParsedImage {
+	ref := image
+	var digest string
+	if idx := strings.Index(ref, "@"); idx != -1 {
+		digest = ref[idx+1:]
+		ref = ref[:idx]
+	}
+
+	registry := ""
+	repoAndTag := ref
+	if idx := strings.Index(ref, "/"); idx != -1 {
+		host := ref[:idx]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			registry = host
+			repoAndTag = ref[idx+1:]
+		}
+	}
+
+	repository := repoAndTag
+	tag := ""
+	if idx := strings.LastIndex(repoAndTag, ":"); idx != -1 && !strings.Contains(repoAndTag[idx:], "/") {
+		repository = repoAndTag[:idx]
+		tag = repoAndTag[idx+1:]
+	} else if digest == "" {
+		tag = "latest"
+	}
+
+	return ParsedImage{
+		Registry:   registry,
+		Repository: repository,
+		Tag:        tag,
+		Digest:     digest,
+	}
+}
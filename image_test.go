@@ -0,0 +1,76 @@
+package main
+
+import "testing"
+
+func TestParseImage(t *testing.T) {
+	cases := []struct {
+		name  string
+		image string
+		want  ParsedImage
+	}{
+		{
+			name:  "bare image defaults to latest",
+			image: "nginx",
+			want:  ParsedImage{Repository: "nginx", Tag: "latest"},
+		},
+		{
+			name:  "docker hub namespaced repo has no registry",
+			image: "library/nginx",
+			want:  ParsedImage{Repository: "library/nginx", Tag: "latest"},
+		},
+		{
+			name:  "registry detected by dot in host",
+			image: "123456789012.dkr.ecr.us-east-1.amazonaws.com/my-app:v1",
+			want: ParsedImage{
+				Registry:   "123456789012.dkr.ecr.us-east-1.amazonaws.com",
+				Repository: "my-app",
+				Tag:        "v1",
+			},
+		},
+		{
+			name:  "registry detected by port colon in host",
+			image: "registry.internal:5000/team/my-app:v2",
+			want: ParsedImage{
+				Registry:   "registry.internal:5000",
+				Repository: "team/my-app",
+				Tag:        "v2",
+			},
+		},
+		{
+			name:  "localhost is treated as a registry",
+			image: "localhost/my-app:dev",
+			want: ParsedImage{
+				Registry:   "localhost",
+				Repository: "my-app",
+				Tag:        "dev",
+			},
+		},
+		{
+			name:  "digest only, no tag",
+			image: "my-app@sha256:abc123",
+			want: ParsedImage{
+				Repository: "my-app",
+				Digest:     "sha256:abc123",
+			},
+		},
+		{
+			name:  "tag and digest both pinned",
+			image: "123456789012.dkr.ecr.us-east-1.amazonaws.com/my-app:v1@sha256:abc123",
+			want: ParsedImage{
+				Registry:   "123456789012.dkr.ecr.us-east-1.amazonaws.com",
+				Repository: "my-app",
+				Tag:        "v1",
+				Digest:     "sha256:abc123",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseImage(tc.image)
+			if got != tc.want {
+				t.Errorf("parseImage(%q) = %+v, want %+v", tc.image, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Package ecr resolves the current digest ECR has for a repository:tag pair,
+// so callers can detect drift between what a task definition is pinned to
+// and what the tag currently points at.
+package ecr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrTypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// ErrTagNotFound is returned when the requested tag (or its repository) no
+// longer exists in ECR.
+var ErrTagNotFound = errors.New("tag not found in ECR repository")
+
+var hostPattern = regexp.MustCompile(`^(\d+)\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com$`)
+
+// IsRegistry reports whether host looks like an ECR registry hostname
+// (<account>.dkr.ecr.<region>.amazonaws.com).
+func IsRegistry(host string) bool {
+	return hostPattern.MatchString(host)
+}
+
+// ParseHost extracts the account ID and region from an ECR registry hostname
+// (<account>.dkr.ecr.<region>.amazonaws.com). ok is false if host doesn't
+// look like an ECR registry.
+func ParseHost(host string) (accountID, region string, ok bool) {
+	m := hostPattern.FindStringSubmatch(host)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// Resolver looks up the current digest for an ECR repository:tag. It keeps a
+// client per region, since a pinned image's registry may belong to a
+// different region (or account, via RegistryId) than the one the caller is
+// scanning.
+type Resolver struct {
+	cfg     aws.Config
+	mu      sync.Mutex
+	clients map[string]*ecr.Client
+}
+
+// NewResolver builds a Resolver using the given AWS config as the base for
+// any per-region clients it needs to construct.
+func NewResolver(cfg aws.Config) *Resolver {
+	return &Resolver{
+		cfg:     cfg,
+		clients: make(map[string]*ecr.Client),
+	}
+}
+
+func (r *Resolver) clientFor(region string) *ecr.Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if region == "" {
+		region = r.cfg.Region
+	}
+	if c, ok := r.clients[region]; ok {
+		return c
+	}
+	regionCfg := r.cfg.Copy()
+	regionCfg.Region = region
+	c := ecr.NewFromConfig(regionCfg)
+	r.clients[region] = c
+	return c
+}
+
+// CurrentDigest returns the digest ECR currently has for repository:tag.
+// registry is the image's registry host as parsed from its reference
+// (e.g. "111122223333.dkr.ecr.eu-west-1.amazonaws.com"); when it names a
+// different account and/or region than the Resolver's own config, the
+// lookup is made against that account (via RegistryId) using a client in
+// that region, so cross-account/cross-region images aren't checked against
+// the wrong registry. CurrentDigest returns ErrTagNotFound if the tag or
+// repository no longer exists.
+func (r *Resolver) CurrentDigest(ctx context.Context, registry, repository, tag string) (string, error) {
+	var registryID *string
+	region := ""
+	if accountID, host, ok := ParseHost(registry); ok {
+		registryID = aws.String(accountID)
+		region = host
+	}
+
+	resp, err := r.clientFor(region).DescribeImages(ctx, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repository),
+		RegistryId:     registryID,
+		ImageIds: []ecrTypes.ImageIdentifier{
+			{ImageTag: aws.String(tag)},
+		},
+	})
+	if err != nil {
+		var imageNotFound *ecrTypes.ImageNotFoundException
+		var repoNotFound *ecrTypes.RepositoryNotFoundException
+		if errors.As(err, &imageNotFound) || errors.As(err, &repoNotFound) {
+			return "", ErrTagNotFound
+		}
+		return "", fmt.Errorf("describe images for %s:%s: %w", repository, tag, err)
+	}
+	if len(resp.ImageDetails) == 0 || resp.ImageDetails[0].ImageDigest == nil {
+		return "", ErrTagNotFound
+	}
+	return *resp.ImageDetails[0].ImageDigest, nil
+}
@@ -2,189 +2,731 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path"
+	"regexp"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/schollz/progressbar/v3"
+	"github.com/xcsrz/ecs-images/internal/ecr"
+	"github.com/xcsrz/ecs-images/pkg/pool"
 )
 
-type ServiceTask struct {
-	ServiceName string
-	ServiceArn  string
+// usageKind classifies how an image came to be discovered, for lifecycle
+// decisions like "is this image still safe to delete from ECR".
+type usageKind string
+
+const (
+	usageRunning    usageKind = "running"       // referenced by a task currently RUNNING
+	usageStopped    usageKind = "stopped"       // referenced by a recently STOPPED task
+	usageTaskDefRef usageKind = "task-def-only" // referenced only by a registered task def, no service
+)
+
+// serviceRef identifies a service within a specific cluster.
+type serviceRef struct {
+	Cluster string
+	Name    string
+	Arn     string
+}
+
+// taskRef identifies a task within a specific cluster.
+type taskRef struct {
+	Cluster string
+	Arn     string
+}
+
+// taskDefRef identifies a task definition within a specific cluster (task
+// definitions are account/region scoped, but we key by cluster too so usage
+// stays grouped per cluster in the final report).
+type taskDefRef struct {
+	Cluster string
+	Arn     string
 }
 
-type TaskInfo struct {
-	TaskArn           string
-	TaskDefinitionArn string
-	ServiceName       string
+// clusterFlags collects repeated -cluster flags into a slice.
+type clusterFlags []string
+
+func (c *clusterFlags) String() string {
+	return strings.Join(*c, ",")
+}
+
+func (c *clusterFlags) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			*c = append(*c, part)
+		}
+	}
+	return nil
+}
+
+// repeatedFlag collects a flag that may be passed more than once, e.g.
+// -service foo -service bar.
+type repeatedFlag []string
+
+func (r *repeatedFlag) String() string {
+	return strings.Join(*r, ",")
+}
+
+func (r *repeatedFlag) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+func (r repeatedFlag) contains(value string) bool {
+	for _, v := range r {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// tagFlag collects repeated -tag key=value flags into a map. A service must
+// match every key/value pair to pass the filter.
+type tagFlag map[string]string
+
+func (t tagFlag) String() string {
+	parts := make([]string, 0, len(t))
+	for k, v := range t {
+		parts = append(parts, k+"="+v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (t *tagFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("invalid --tag %q, want key=value", value)
+	}
+	if *t == nil {
+		*t = make(tagFlag)
+	}
+	(*t)[key] = val
+	return nil
+}
+
+// desiredStatuses are the task lifecycle states we scan for. Scanning both
+// means an image referenced only by a recently stopped task is still
+// reported, instead of silently disappearing from the inventory.
+var desiredStatuses = []ecsTypes.DesiredStatus{
+	ecsTypes.DesiredStatusRunning,
+	ecsTypes.DesiredStatusStopped,
 }
 
 func main() {
-	cluster := flag.String("cluster", "", "ECS cluster name (required)")
+	var clusters clusterFlags
+	flag.Var(&clusters, "cluster", "ECS cluster name (repeatable or comma-separated)")
+	allClusters := flag.Bool("all-clusters", false, "discover and scan every cluster in the region")
 	region := flag.String("region", "us-east-1", "AWS region (default: us-east-1)")
+	output := flag.String("output", "text", "output format: text, json, csv, or ndjson")
+	pretty := flag.Bool("pretty", false, "pretty-print JSON output")
+	concurrency := flag.Int("concurrency", 5, "max concurrent API calls per phase")
+	retryCount := flag.Int("retry-count", 5, "max retry attempts for throttled/failed AWS API calls")
+	retryMaxDelay := flag.Duration("retry-max-delay", 20*time.Second, "max backoff delay between retries")
+	var serviceNames repeatedFlag
+	flag.Var(&serviceNames, "service", "only scan this service name (repeatable)")
+	serviceRegex := flag.String("service-regex", "", "only scan services whose name matches this regex")
+	var tagFilters tagFlag
+	flag.Var(&tagFilters, "tag", "only scan services tagged key=value (repeatable, all must match)")
+	var excludeImages repeatedFlag
+	flag.Var(&excludeImages, "exclude-image", "glob pattern of image(s) to drop from the report (repeatable)")
 	flag.Parse()
 
-	if *cluster == "" {
-		fmt.Println("--cluster is required")
+	if !*allClusters && len(clusters) == 0 {
+		fmt.Fprintln(os.Stderr, "--cluster (or --all-clusters) is required")
 		os.Exit(1)
 	}
 
+	var serviceNameRegex *regexp.Regexp
+	if *serviceRegex != "" {
+		var err error
+		serviceNameRegex, err = regexp.Compile(*serviceRegex)
+		if err != nil {
+			log.Fatalf("invalid --service-regex: %v", err)
+		}
+	}
+
+	reporter, err := NewReporter(*output, *pretty)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(*region))
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(*region),
+		config.WithRetryer(func() aws.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				o.MaxAttempts = *retryCount
+				o.MaxBackoff = *retryMaxDelay
+			})
+		}),
+	)
 	if err != nil {
 		log.Fatalf("failed to load AWS config: %v", err)
 	}
 	client := ecs.NewFromConfig(cfg)
 
-	fmt.Printf("Fetching services in cluster '%s'...\n", *cluster)
-	serviceArns := []string{}
-	paginator := ecs.NewListServicesPaginator(client, &ecs.ListServicesInput{Cluster: cluster})
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+	if *allClusters {
+		clusters, err = discoverClusters(ctx, client)
 		if err != nil {
-			log.Fatalf("failed to list services: %v", err)
+			log.Fatalf("failed to discover clusters: %v", err)
 		}
-		serviceArns = append(serviceArns, page.ServiceArns...)
+		if len(clusters) == 0 {
+			fmt.Fprintln(os.Stderr, "No clusters found.")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Discovered %d cluster(s): %s\n", len(clusters), strings.Join(clusters, ", "))
 	}
-	if len(serviceArns) == 0 {
-		fmt.Println("No services found.")
+
+	var apiErrs []error
+
+	// Phase 1: list services for every cluster.
+	fmt.Fprintln(os.Stderr, "Fetching services...")
+	var services []serviceRef
+	for _, cluster := range clusters {
+		serviceArns, err := listServiceArns(ctx, client, cluster)
+		if err != nil {
+			apiErrs = append(apiErrs, fmt.Errorf("cluster %s: failed to list services: %w", cluster, err))
+			continue
+		}
+		for _, arn := range serviceArns {
+			parts := splitArn(arn)
+			services = append(services, serviceRef{Cluster: cluster, Name: parts[len(parts)-1], Arn: arn})
+		}
+	}
+	if len(services) == 0 {
+		fmt.Fprintln(os.Stderr, "No services found.")
 		return
 	}
 
-	serviceNames := make([]string, len(serviceArns))
-	serviceNameToArn := make(map[string]string)
-	for i, arn := range serviceArns {
-		parts := splitArn(arn)
-		serviceNames[i] = parts[len(parts)-1]
-		serviceNameToArn[serviceNames[i]] = arn
+	// Filter by name/regex first since it's free, then by tag (which costs
+	// one ListTagsForResource call per remaining service), all before the
+	// much more expensive ListTasks/DescribeTasks fan-out below.
+	if len(serviceNames) > 0 || serviceNameRegex != nil {
+		filtered := services[:0]
+		for _, svc := range services {
+			if len(serviceNames) > 0 && !serviceNames.contains(svc.Name) {
+				continue
+			}
+			if serviceNameRegex != nil && !serviceNameRegex.MatchString(svc.Name) {
+				continue
+			}
+			filtered = append(filtered, svc)
+		}
+		services = filtered
+	}
+
+	if len(tagFilters) > 0 {
+		fmt.Fprintln(os.Stderr, "Filtering services by tag...")
+		var tagMu sync.Mutex
+		var filtered []serviceRef
+		tagPool := pool.New(*concurrency)
+		for _, svc := range services {
+			svc := svc
+			tagPool.Go(func() error {
+				resp, err := client.ListTagsForResource(ctx, &ecs.ListTagsForResourceInput{
+					ResourceArn: aws.String(svc.Arn),
+				})
+				if err != nil {
+					return fmt.Errorf("list tags for service %s: %w", svc.Arn, err)
+				}
+				tags := make(map[string]string, len(resp.Tags))
+				for _, t := range resp.Tags {
+					tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+				}
+				for key, want := range tagFilters {
+					if got, ok := tags[key]; !ok || got != want {
+						return nil
+					}
+				}
+				tagMu.Lock()
+				filtered = append(filtered, svc)
+				tagMu.Unlock()
+				return nil
+			})
+		}
+		apiErrs = append(apiErrs, tagPool.Wait()...)
+		services = filtered
+	}
+
+	if len(services) == 0 {
+		fmt.Fprintln(os.Stderr, "No services match the given filters.")
+		return
 	}
 
-	fmt.Println("Fetching task ARNs for each service...")
-	taskArns := []string{}
-	taskToService := make(map[string]string)
+	// Phase 2: fan out ListTasks across every (cluster, service, desired
+	// status) triple behind a single worker pool and a single aggregated
+	// progress bar.
+	fmt.Fprintln(os.Stderr, "Fetching task ARNs for each service...")
+	type listJob struct {
+		svc    serviceRef
+		status ecsTypes.DesiredStatus
+	}
+	var jobs []listJob
+	for _, svc := range services {
+		for _, status := range desiredStatuses {
+			jobs = append(jobs, listJob{svc: svc, status: status})
+		}
+	}
 
-	// Semaphore worker pool for listing tasks
-	sem := make(chan struct{}, 5)
-	var wg sync.WaitGroup
+	taskToService := make(map[taskRef]string)
+	taskStatus := make(map[taskRef]usageKind)
 	var mu sync.Mutex
-	bar := progressbar.Default(int64(len(serviceNames)), "Listing tasks")
-
-	for _, serviceName := range serviceNames {
-		wg.Add(1)
-		sem <- struct{}{}
-		go func(svcName string) {
-			defer wg.Done()
-			defer func() { <-sem }()
-			resp, err := client.ListTasks(ctx, &ecs.ListTasksInput{
-				Cluster:     cluster,
-				ServiceName: aws.String(svcName),
+
+	bar := newBar(int64(len(jobs)), "Listing tasks")
+	listPool := pool.New(*concurrency)
+	for _, job := range jobs {
+		job := job
+		listPool.Go(func() error {
+			defer bar.Add(1)
+			kind := usageRunning
+			if job.status == ecsTypes.DesiredStatusStopped {
+				kind = usageStopped
+			}
+			var taskArns []string
+			paginator := ecs.NewListTasksPaginator(client, &ecs.ListTasksInput{
+				Cluster:       aws.String(job.svc.Cluster),
+				ServiceName:   aws.String(job.svc.Name),
+				DesiredStatus: job.status,
 			})
-			if err == nil {
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					return fmt.Errorf("list tasks for %s/%s (%s): %w", job.svc.Cluster, job.svc.Name, job.status, err)
+				}
+				taskArns = append(taskArns, page.TaskArns...)
+			}
+			mu.Lock()
+			for _, t := range taskArns {
+				ref := taskRef{Cluster: job.svc.Cluster, Arn: t}
+				taskToService[ref] = job.svc.Name
+				// RUNNING takes priority if a task somehow appears in both listings.
+				if existing, ok := taskStatus[ref]; !ok || existing == usageStopped {
+					taskStatus[ref] = kind
+				}
+			}
+			mu.Unlock()
+			return nil
+		})
+	}
+	apiErrs = append(apiErrs, listPool.Wait()...)
+	bar.Finish()
+
+	if len(taskToService) == 0 {
+		fmt.Fprintln(os.Stderr, "No tasks found.")
+	}
+
+	// Phase 3: DescribeTasks is cluster-scoped, so batch per cluster, but
+	// report progress against a single bar sized by total batch count.
+	fmt.Fprintln(os.Stderr, "Describing tasks to get task definitions...")
+	tasksByCluster := make(map[string][]string)
+	for ref := range taskToService {
+		tasksByCluster[ref.Cluster] = append(tasksByCluster[ref.Cluster], ref.Arn)
+	}
+	totalBatches := 0
+	for _, arns := range tasksByCluster {
+		totalBatches += (len(arns) + 99) / 100
+	}
+
+	// taskDefUsage tracks, per task definition, which services reference it
+	// via a running task, which only via a stopped one, and the launch
+	// type(s) observed on the underlying tasks.
+	type taskDefUsage struct {
+		running     map[string]struct{}
+		stopped     map[string]struct{}
+		launchTypes map[string]struct{}
+	}
+	taskDefUsages := make(map[taskDefRef]*taskDefUsage)
+
+	if totalBatches > 0 {
+		bar = newBar(int64(totalBatches), "Describing tasks")
+		describePool := pool.New(*concurrency)
+		for cluster, arns := range tasksByCluster {
+			cluster := cluster
+			for i := 0; i < len(arns); i += 100 {
+				end := i + 100
+				if end > len(arns) {
+					end = len(arns)
+				}
+				batch := arns[i:end]
+				describePool.Go(func() error {
+					defer bar.Add(1)
+					resp, err := client.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+						Cluster: aws.String(cluster),
+						Tasks:   batch,
+					})
+					if err != nil {
+						return fmt.Errorf("describe tasks in cluster %s: %w", cluster, err)
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					for _, task := range resp.Tasks {
+						tref := taskRef{Cluster: cluster, Arn: *task.TaskArn}
+						ref := taskDefRef{Cluster: cluster, Arn: *task.TaskDefinitionArn}
+						serviceName := taskToService[tref]
+						usage, ok := taskDefUsages[ref]
+						if !ok {
+							usage = &taskDefUsage{
+								running:     make(map[string]struct{}),
+								stopped:     make(map[string]struct{}),
+								launchTypes: make(map[string]struct{}),
+							}
+							taskDefUsages[ref] = usage
+						}
+						if taskStatus[tref] == usageStopped {
+							usage.stopped[serviceName] = struct{}{}
+						} else {
+							usage.running[serviceName] = struct{}{}
+						}
+						if task.LaunchType != "" {
+							usage.launchTypes[string(task.LaunchType)] = struct{}{}
+						}
+					}
+					return nil
+				})
+			}
+		}
+		apiErrs = append(apiErrs, describePool.Wait()...)
+		bar.Finish()
+	}
+
+	// Phase 3b: enumerate task definition families and their latest active
+	// revision, so families that are registered/pinned but have no running
+	// or stopped task still show up in the report.
+	fmt.Fprintln(os.Stderr, "Enumerating task definition families...")
+	familyArns, err := latestActiveTaskDefinitionArns(ctx, client)
+	if err != nil {
+		apiErrs = append(apiErrs, fmt.Errorf("failed to enumerate task definition families: %w", err))
+	}
+	inUse := make(map[string]struct{})
+	for ref := range taskDefUsages {
+		inUse[ref.Arn] = struct{}{}
+	}
+	var familyOnlyArns []string
+	for _, arn := range familyArns {
+		if _, ok := inUse[arn]; !ok {
+			familyOnlyArns = append(familyOnlyArns, arn)
+		}
+	}
+
+	// Phase 4: fan out DescribeTaskDefinition across every in-use task def
+	// plus every family-only task def, behind the same shared worker pool
+	// and a single progress bar.
+	fmt.Fprintln(os.Stderr, "Describing task definitions to get container images...")
+	records := make(map[string]*ImageRecord)
+
+	taskDefList := make([]taskDefRef, 0, len(taskDefUsages)+len(familyOnlyArns))
+	for ref := range taskDefUsages {
+		taskDefList = append(taskDefList, ref)
+	}
+	for _, arn := range familyOnlyArns {
+		taskDefList = append(taskDefList, taskDefRef{Arn: arn})
+	}
+
+	bar = newBar(int64(len(taskDefList)), "Describing task defs")
+	describeTaskDefPool := pool.New(*concurrency)
+	for _, ref := range taskDefList {
+		ref := ref
+		describeTaskDefPool.Go(func() error {
+			defer bar.Add(1)
+			resp, err := client.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+				TaskDefinition: aws.String(ref.Arn),
+			})
+			if err != nil {
+				return fmt.Errorf("describe task definition %s: %w", ref.Arn, err)
+			}
+			usage := taskDefUsages[ref]
+			for _, container := range resp.TaskDefinition.ContainerDefinitions {
+				image := *container.Image
+				parsed := parseImage(image)
 				mu.Lock()
-				taskArns = append(taskArns, resp.TaskArns...)
-				for _, t := range resp.TaskArns {
-					taskToService[t] = svcName
+				if usage == nil {
+					addImageUsage(records, image, parsed, "", "", string(usageTaskDefRef), ref.Arn, "")
+				} else {
+					launchType := strings.Join(setToSlice(usage.launchTypes), ",")
+					for svc := range usage.running {
+						addImageUsage(records, image, parsed, ref.Cluster, svc, string(usageRunning), ref.Arn, launchType)
+					}
+					for svc := range usage.stopped {
+						addImageUsage(records, image, parsed, ref.Cluster, svc, string(usageStopped), ref.Arn, launchType)
+					}
 				}
 				mu.Unlock()
 			}
-			bar.Add(1)
-		}(serviceName)
+			return nil
+		})
 	}
-	wg.Wait()
+	apiErrs = append(apiErrs, describeTaskDefPool.Wait()...)
 	bar.Finish()
 
-	if len(taskArns) == 0 {
-		fmt.Println("No tasks found.")
-		return
+	// An image seen on a running or stopped task is not task-def-only, even
+	// if an unused registered revision references it too.
+	usedByTask := make(map[string]struct{})
+	for _, rec := range records {
+		if rec.UsageKind != string(usageTaskDefRef) {
+			usedByTask[rec.Image] = struct{}{}
+		}
 	}
 
-	fmt.Println("Describing tasks to get task definitions...")
-	taskDefArns := make(map[string]struct{})
-	taskDefToService := make(map[string]map[string]struct{})
-	bar = progressbar.Default(int64((len(taskArns)+99)/100), "Describing tasks")
-	for i := 0; i < len(taskArns); i += 100 {
-		end := i + 100
-		if end > len(taskArns) {
-			end = len(taskArns)
+	recordList := make([]ImageRecord, 0, len(records))
+	for _, rec := range records {
+		if matchesAnyGlob(excludeImages, rec.Image, rec.Repository) {
+			continue
 		}
-		resp, err := client.DescribeTasks(ctx, &ecs.DescribeTasksInput{
-			Cluster: cluster,
-			Tasks:   taskArns[i:end],
-		})
-		if err != nil {
+		if _, ok := usedByTask[rec.Image]; ok && rec.UsageKind == string(usageTaskDefRef) {
 			continue
 		}
-		for _, task := range resp.Tasks {
-			taskDefArns[*task.TaskDefinitionArn] = struct{}{}
-			serviceName := taskToService[*task.TaskArn]
-			if _, ok := taskDefToService[*task.TaskDefinitionArn]; !ok {
-				taskDefToService[*task.TaskDefinitionArn] = make(map[string]struct{})
-			}
-			taskDefToService[*task.TaskDefinitionArn][serviceName] = struct{}{}
+		recordList = append(recordList, *rec)
+	}
+
+	apiErrs = append(apiErrs, reportImageDigestDrift(ctx, cfg, recordList, *concurrency)...)
+
+	if err := reporter.Report(os.Stdout, recordList); err != nil {
+		log.Fatalf("failed to write report: %v", err)
+	}
+
+	if len(apiErrs) > 0 {
+		fmt.Fprintf(os.Stderr, "\n%d API call(s) failed:\n", len(apiErrs))
+		for _, e := range apiErrs {
+			fmt.Fprintf(os.Stderr, "  - %v\n", e)
 		}
-		bar.Add(1)
+		os.Exit(1)
 	}
-	bar.Finish()
+}
 
-	fmt.Println("Describing task definitions to get container images...")
-	imageToServices := make(map[string]map[string]struct{})
-
-	taskDefList := make([]string, 0, len(taskDefArns))
-	for arn := range taskDefArns {
-		taskDefList = append(taskDefList, arn)
-	}
-	bar = progressbar.Default(int64(len(taskDefList)), "Describing task defs")
-	sem = make(chan struct{}, 5)
-	wg = sync.WaitGroup{}
-	mu = sync.Mutex{}
-	for _, taskDefArn := range taskDefList {
-		wg.Add(1)
-		sem <- struct{}{}
-		go func(tdArn string) {
-			defer wg.Done()
-			defer func() { <-sem }()
-			resp, err := client.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
-				TaskDefinition: aws.String(tdArn),
-			})
-			if err == nil {
-				services := taskDefToService[tdArn]
-				for _, container := range resp.TaskDefinition.ContainerDefinitions {
-					image := *container.Image
+// driftIssue describes a single image whose running/registered reference no
+// longer matches what's currently in ECR.
+type driftIssue struct {
+	Repository    string
+	Tag           string
+	PinnedDigest  string
+	CurrentDigest string
+	TagDeleted    bool
+}
+
+// reportImageDigestDrift resolves the current digest for every ECR-hosted
+// image's tag and flags cases where a task is pinned to a digest that no
+// longer matches the tag, or where the tag has been deleted entirely. It
+// prints to stderr so it doesn't disturb the structured report on stdout,
+// and returns any API errors encountered so the caller can surface them.
+func reportImageDigestDrift(ctx context.Context, cfg aws.Config, recordList []ImageRecord, concurrency int) []error {
+	resolver := ecr.NewResolver(cfg)
+
+	seen := make(map[string]struct{})
+	type lookup struct {
+		registry     string
+		repository   string
+		tag          string
+		pinnedDigest string
+	}
+	var lookups []lookup
+	for _, rec := range recordList {
+		if rec.Tag == "" || !ecr.IsRegistry(rec.Registry) {
+			continue
+		}
+		// Key includes pinnedDigest: a rolling deploy can have the same
+		// repository:tag pinned to different digests across tasks/clusters,
+		// and each distinct pin needs to be checked for drift independently.
+		key := rec.Repository + ":" + rec.Tag + "@" + rec.Digest
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		lookups = append(lookups, lookup{registry: rec.Registry, repository: rec.Repository, tag: rec.Tag, pinnedDigest: rec.Digest})
+	}
+	if len(lookups) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, "Resolving ECR digests to detect drift...")
+	var mu sync.Mutex
+	var issues []driftIssue
+	bar := newBar(int64(len(lookups)), "Resolving ECR digests")
+	digestPool := pool.New(concurrency)
+	for _, l := range lookups {
+		l := l
+		digestPool.Go(func() error {
+			defer bar.Add(1)
+			digest, err := resolver.CurrentDigest(ctx, l.registry, l.repository, l.tag)
+			if err != nil {
+				if errors.Is(err, ecr.ErrTagNotFound) {
 					mu.Lock()
-					if _, ok := imageToServices[image]; !ok {
-						imageToServices[image] = make(map[string]struct{})
-					}
-					for svc := range services {
-						imageToServices[image][svc] = struct{}{}
-					}
+					issues = append(issues, driftIssue{Repository: l.repository, Tag: l.tag, PinnedDigest: l.pinnedDigest, TagDeleted: true})
 					mu.Unlock()
+					return nil
 				}
+				return fmt.Errorf("resolve digest for %s:%s: %w", l.repository, l.tag, err)
+			}
+			if l.pinnedDigest != "" && l.pinnedDigest != digest {
+				mu.Lock()
+				issues = append(issues, driftIssue{Repository: l.repository, Tag: l.tag, PinnedDigest: l.pinnedDigest, CurrentDigest: digest})
+				mu.Unlock()
 			}
-			bar.Add(1)
-		}(taskDefArn)
+			return nil
+		})
 	}
-	wg.Wait()
+	errs := digestPool.Wait()
 	bar.Finish()
 
-	fmt.Println("\nUnique container image URIs and services using them:")
-	for image, services := range imageToServices {
-		fmt.Println(image)
-		if len(services) > 0 {
-			fmt.Println("  Services:")
-			for svc := range services {
-				fmt.Printf("    - %s\n", svc)
+	if len(issues) > 0 {
+		fmt.Fprintln(os.Stderr, "\nImage digest drift detected:")
+		for _, issue := range issues {
+			if issue.TagDeleted {
+				fmt.Fprintf(os.Stderr, "  %s:%s: tag has been deleted from ECR\n", issue.Repository, issue.Tag)
+			} else {
+				fmt.Fprintf(os.Stderr, "  %s:%s: pinned digest %s no longer matches the tag (current: %s)\n",
+					issue.Repository, issue.Tag, issue.PinnedDigest, issue.CurrentDigest)
 			}
-		} else {
-			fmt.Println("  No active services using this image")
 		}
-		fmt.Println()
 	}
+	return errs
+}
+
+// addImageUsage merges a single (image, cluster, service, kind) observation
+// into records, keyed so that repeated observations of the same image for
+// the same cluster+kind accumulate into one record's Services/TaskDefinitionArns.
+func addImageUsage(records map[string]*ImageRecord, image string, parsed ParsedImage, cluster, service, kind, taskDefArn, launchType string) {
+	key := image + "|" + cluster + "|" + kind
+	rec, ok := records[key]
+	if !ok {
+		rec = &ImageRecord{
+			Image:              image,
+			Registry:           parsed.Registry,
+			Repository:         parsed.Repository,
+			Tag:                parsed.Tag,
+			Digest:             parsed.Digest,
+			Cluster:            cluster,
+			UsageKind:          kind,
+			Services:           []string{},
+			TaskDefinitionArns: []string{},
+			LaunchType:         launchType,
+		}
+		records[key] = rec
+	}
+	if service != "" && !containsString(rec.Services, service) {
+		rec.Services = append(rec.Services, service)
+	}
+	if !containsString(rec.TaskDefinitionArns, taskDefArn) {
+		rec.TaskDefinitionArns = append(rec.TaskDefinitionArns, taskDefArn)
+	}
+}
+
+// matchesAnyGlob reports whether image or repository matches any of the
+// given shell glob patterns (e.g. "amazon/amazon-ecs-pause", "datadog/*").
+func matchesAnyGlob(patterns []string, image, repository string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, image); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, repository); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
+
+func setToSlice(set map[string]struct{}) []string {
+	out := make([]string, 0, len(set))
+	for s := range set {
+		out = append(out, s)
+	}
+	return out
+}
+
+// newBar creates a progress bar written to stderr so stdout stays
+// machine-parseable for the structured output formats.
+func newBar(max int64, description string) *progressbar.ProgressBar {
+	return progressbar.NewOptions64(max,
+		progressbar.OptionSetDescription(description),
+		progressbar.OptionSetWriter(os.Stderr),
+	)
+}
+
+// discoverClusters returns the name of every cluster in the configured region.
+func discoverClusters(ctx context.Context, client *ecs.Client) ([]string, error) {
+	clusterArns := []string{}
+	paginator := ecs.NewListClustersPaginator(client, &ecs.ListClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list clusters: %w", err)
+		}
+		clusterArns = append(clusterArns, page.ClusterArns...)
+	}
+	clusterNames := make([]string, len(clusterArns))
+	for i, arn := range clusterArns {
+		parts := splitArn(arn)
+		clusterNames[i] = parts[len(parts)-1]
+	}
+	return clusterNames, nil
+}
+
+// listServiceArns returns every service ARN in the given cluster.
+func listServiceArns(ctx context.Context, client *ecs.Client, cluster string) ([]string, error) {
+	serviceArns := []string{}
+	paginator := ecs.NewListServicesPaginator(client, &ecs.ListServicesInput{Cluster: aws.String(cluster)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		serviceArns = append(serviceArns, page.ServiceArns...)
+	}
+	return serviceArns, nil
+}
+
+// latestActiveTaskDefinitionArns enumerates every task definition family and
+// resolves the latest ACTIVE revision ARN for each.
+func latestActiveTaskDefinitionArns(ctx context.Context, client *ecs.Client) ([]string, error) {
+	var families []string
+	famPaginator := ecs.NewListTaskDefinitionFamiliesPaginator(client, &ecs.ListTaskDefinitionFamiliesInput{
+		Status: ecsTypes.TaskDefinitionFamilyStatusActive,
+	})
+	for famPaginator.HasMorePages() {
+		page, err := famPaginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list task definition families: %w", err)
+		}
+		families = append(families, page.Families...)
+	}
+
+	arns := make([]string, 0, len(families))
+	for _, family := range families {
+		resp, err := client.ListTaskDefinitions(ctx, &ecs.ListTaskDefinitionsInput{
+			FamilyPrefix: aws.String(family),
+			Status:       ecsTypes.TaskDefinitionStatusActive,
+			Sort:         ecsTypes.SortOrderDesc,
+			MaxResults:   aws.Int32(1),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list task definitions for family %s: %w", family, err)
+		}
+		if len(resp.TaskDefinitionArns) > 0 {
+			arns = append(arns, resp.TaskDefinitionArns[0])
+		}
+	}
+	return arns, nil
 }
 
 func splitArn(arn string) []string {
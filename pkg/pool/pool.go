@@ -0,0 +1,46 @@
+// Package pool provides a small bounded worker pool that collects errors
+// from its tasks instead of dropping them, so callers can surface every
+// failure once the fan-out completes rather than silently continuing.
+package pool
+
+import "sync"
+
+// Pool runs tasks with at most n running concurrently.
+type Pool struct {
+	sem  chan struct{}
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	errs []error
+}
+
+// New returns a Pool that allows up to concurrency tasks to run at once.
+// concurrency <= 0 is treated as 1.
+func New(concurrency int) *Pool {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &Pool{sem: make(chan struct{}, concurrency)}
+}
+
+// Go runs fn in the pool. If fn returns a non-nil error, it's recorded and
+// returned by Wait; it does not stop other in-flight or queued tasks.
+func (p *Pool) Go(fn func() error) {
+	p.wg.Add(1)
+	p.sem <- struct{}{}
+	go func() {
+		defer p.wg.Done()
+		defer func() { <-p.sem }()
+		if err := fn(); err != nil {
+			p.mu.Lock()
+			p.errs = append(p.errs, err)
+			p.mu.Unlock()
+		}
+	}()
+}
+
+// Wait blocks until every submitted task has finished and returns every
+// error they produced, in completion order.
+func (p *Pool) Wait() []error {
+	p.wg.Wait()
+	return p.errs
+}
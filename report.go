@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ImageRecord is the stable, per-(image, cluster, usage) unit the structured
+// reporters emit. It's deliberately flat so it can be piped straight into an
+// inventory or vulnerability-scanning pipeline without further joins.
+type ImageRecord struct {
+	Image              string   `json:"image"`
+	Registry           string   `json:"registry"`
+	Repository         string   `json:"repository"`
+	Tag                string   `json:"tag,omitempty"`
+	Digest             string   `json:"digest,omitempty"`
+	Cluster            string   `json:"cluster,omitempty"`
+	UsageKind          string   `json:"usageKind"`
+	Services           []string `json:"services"`
+	TaskDefinitionArns []string `json:"taskDefinitionArns"`
+	LaunchType         string   `json:"launchType,omitempty"`
+}
+
+// Reporter renders a set of ImageRecords in a specific output format.
+type Reporter interface {
+	Report(w io.Writer, records []ImageRecord) error
+}
+
+// NewReporter returns the Reporter for the given --output format.
+func NewReporter(format string, pretty bool) (Reporter, error) {
+	switch format {
+	case "", "text":
+		return textReporter{}, nil
+	case "json":
+		return jsonReporter{pretty: pretty}, nil
+	case "ndjson":
+		return ndjsonReporter{}, nil
+	case "csv":
+		return csvReporter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want text, json, csv, or ndjson)", format)
+	}
+}
+
+// sortRecords orders records for stable, diffable output.
+func sortRecords(records []ImageRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Image != records[j].Image {
+			return records[i].Image < records[j].Image
+		}
+		if records[i].Cluster != records[j].Cluster {
+			return records[i].Cluster < records[j].Cluster
+		}
+		return records[i].UsageKind < records[j].UsageKind
+	})
+}
+
+type jsonReporter struct{ pretty bool }
+
+func (r jsonReporter) Report(w io.Writer, records []ImageRecord) error {
+	sortRecords(records)
+	enc := json.NewEncoder(w)
+	if r.pretty {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(records)
+}
+
+type ndjsonReporter struct{}
+
+func (ndjsonReporter) Report(w io.Writer, records []ImageRecord) error {
+	sortRecords(records)
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type csvReporter struct{}
+
+func (csvReporter) Report(w io.Writer, records []ImageRecord) error {
+	sortRecords(records)
+	cw := csv.NewWriter(w)
+	header := []string{"image", "registry", "repository", "tag", "digest", "cluster", "usageKind", "services", "taskDefinitionArns", "launchType"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		row := []string{
+			rec.Image,
+			rec.Registry,
+			rec.Repository,
+			rec.Tag,
+			rec.Digest,
+			rec.Cluster,
+			rec.UsageKind,
+			strings.Join(rec.Services, ";"),
+			strings.Join(rec.TaskDefinitionArns, ";"),
+			rec.LaunchType,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type textReporter struct{}
+
+func (textReporter) Report(w io.Writer, records []ImageRecord) error {
+	sortRecords(records)
+
+	byImage := make(map[string][]ImageRecord)
+	var images []string
+	for _, rec := range records {
+		if _, ok := byImage[rec.Image]; !ok {
+			images = append(images, rec.Image)
+		}
+		byImage[rec.Image] = append(byImage[rec.Image], rec)
+	}
+
+	fmt.Fprintln(w, "Unique container image URIs and how they're used:")
+	for _, image := range images {
+		fmt.Fprintln(w, image)
+		recs := byImage[image]
+
+		byCluster := make(map[string]map[string][]string)
+		var taskDefOnly bool
+		for _, rec := range recs {
+			if rec.UsageKind == string(usageTaskDefRef) {
+				taskDefOnly = true
+				continue
+			}
+			if _, ok := byCluster[rec.Cluster]; !ok {
+				byCluster[rec.Cluster] = make(map[string][]string)
+			}
+			byCluster[rec.Cluster][rec.UsageKind] = append(byCluster[rec.Cluster][rec.UsageKind], rec.Services...)
+		}
+
+		if len(byCluster) == 0 && !taskDefOnly {
+			fmt.Fprintln(w, "  No active services using this image")
+		}
+		for cluster, byKind := range byCluster {
+			fmt.Fprintf(w, "  Cluster: %s\n", cluster)
+			if svcs, ok := byKind[string(usageRunning)]; ok {
+				fmt.Fprintln(w, "    Running:")
+				for _, svc := range svcs {
+					fmt.Fprintf(w, "      - %s\n", svc)
+				}
+			}
+			if svcs, ok := byKind[string(usageStopped)]; ok {
+				fmt.Fprintln(w, "    Stopped:")
+				for _, svc := range svcs {
+					fmt.Fprintf(w, "      - %s\n", svc)
+				}
+			}
+		}
+		if taskDefOnly {
+			fmt.Fprintln(w, "  Only referenced by a registered task definition (no active service)")
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
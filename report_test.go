@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+)
+
+func testRecords() []ImageRecord {
+	return []ImageRecord{
+		{
+			Image:              "my-app:v2",
+			Registry:           "",
+			Repository:         "my-app",
+			Tag:                "v2",
+			Cluster:            "prod",
+			UsageKind:          string(usageRunning),
+			Services:           []string{"web"},
+			TaskDefinitionArns: []string{"arn:aws:ecs:task-def/my-app:2"},
+		},
+		{
+			Image:              "my-app:v1",
+			Registry:           "",
+			Repository:         "my-app",
+			Tag:                "v1",
+			Cluster:            "",
+			UsageKind:          string(usageTaskDefRef),
+			Services:           []string{},
+			TaskDefinitionArns: []string{"arn:aws:ecs:task-def/my-app:1"},
+		},
+	}
+}
+
+func TestJSONReporterOmitsNullSlices(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (jsonReporter{}).Report(&buf, testRecords()); err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+
+	var got []ImageRecord
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal output: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d records, want 2", len(got))
+	}
+
+	// sortRecords orders by image, so "my-app:v1" (task-def-only) comes first.
+	if got[0].Image != "my-app:v1" || got[1].Image != "my-app:v2" {
+		t.Fatalf("records not sorted by image: %+v", got)
+	}
+
+	if !bytes.Contains(buf.Bytes(), []byte(`"services":[]`)) {
+		t.Errorf("expected services to serialize as [], not null, got: %s", buf.String())
+	}
+	if bytes.Contains(buf.Bytes(), []byte("null")) {
+		t.Errorf("output should never contain a null slice, got: %s", buf.String())
+	}
+}
+
+func TestCSVReporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (csvReporter{}).Report(&buf, testRecords()); err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+
+	r := csv.NewReader(&buf)
+	rows, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("parse CSV output: %v", err)
+	}
+	if len(rows) != 3 { // header + 2 records
+		t.Fatalf("got %d rows, want 3: %v", len(rows), rows)
+	}
+	if rows[0][0] != "image" {
+		t.Errorf("unexpected header: %v", rows[0])
+	}
+
+	// Sorted by image: "my-app:v1" first.
+	if rows[1][0] != "my-app:v1" || rows[1][len(rows[1])-2] != "arn:aws:ecs:task-def/my-app:1" {
+		t.Errorf("unexpected first record row: %v", rows[1])
+	}
+	// Second row is the running record; usageKind is column 6.
+	if rows[2][0] != "my-app:v2" || rows[2][6] != string(usageRunning) {
+		t.Errorf("unexpected second record row: %v", rows[2])
+	}
+}